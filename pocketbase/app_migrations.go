@@ -0,0 +1,16 @@
+// app_migrations.go
+// アプリ固有のモジュールマイグレーションをここに登録する。
+// 機能領域（モジュール）ごとに RegisterMigration を呼び、
+// fromVersion -> fromVersion+1 の順でアップグレードを積み上げていく。
+package main
+
+// registerAppMigrations はこのアプリが提供するモジュールマイグレーションを
+// Configurator に登録する。新しいモジュールや新バージョンを追加する場合は
+// ここに RegisterMigration 呼び出しを追加する。
+func registerAppMigrations(c *Configurator) {
+	// 例:
+	// c.RegisterMigration("billing", 0, func(app core.App) error {
+	// 	// v0 -> v1 のアップグレード処理
+	// 	return nil
+	// })
+}
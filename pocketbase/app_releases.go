@@ -0,0 +1,19 @@
+// app_releases.go
+// アプリ固有の releasecmd リリースをここに登録する。
+package main
+
+// registerAppReleases はこのアプリが提供する releasecmd リリースを
+// ReleaseRegistry に登録する。S3 バケットポリシーや Webhook 登録、
+// シード管理者、OAuth プロバイダ設定などを追加する場合はここに
+// registry.Register(...) 呼び出しを追加する。
+func registerAppReleases(registry *ReleaseRegistry) {
+	// 例:
+	// registry.Register(&Release{
+	// 	Name:      "seed-admin",
+	// 	DependsOn: nil,
+	// 	Source:    "seed-admin@v1",
+	// 	Up: func(app core.App) error {
+	// 		return nil
+	// 	},
+	// })
+}
@@ -0,0 +1,342 @@
+// cors.go
+// pb_data/cors.yaml（または環境変数 PB_CORS_CONFIG）から読み込む
+// カスタム CORS サブシステム。オリジンの glob/regex 許可リスト、
+// credentials モード、メソッド/ヘッダ許可リスト、プリフライトの
+// キャッシュ時間（Access-Control-Max-Age）をルートごとに設定できる。
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// corsPidFilePath is where the running serve process records its PID so
+// that a separate `cors reload` invocation can signal it. Without this, a
+// CLI reload command only mutates a brand-new process's own in-memory
+// config and never touches the already-running server.
+func corsPidFilePath() string {
+	if p := os.Getenv("PB_CORS_PID_FILE"); p != "" {
+		return p
+	}
+	return filepath.Join("pb_data", "cors.pid")
+}
+
+// corsOriginRule は 1 つのオリジン許可パターンを表す。
+// Pattern が "^" で始まる場合は正規表現、それ以外は glob（"*" のみサポート）として扱う。
+type corsOriginRule struct {
+	Pattern string
+	re      *regexp.Regexp // 正規表現の場合のみセット
+}
+
+func (r *corsOriginRule) compile() error {
+	if strings.HasPrefix(r.Pattern, "^") {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("cors: invalid regex origin pattern %q: %w", r.Pattern, err)
+		}
+		r.re = re
+		return nil
+	}
+	return nil
+}
+
+func (r *corsOriginRule) matches(origin string) bool {
+	if r.re != nil {
+		return r.re.MatchString(origin)
+	}
+	return globMatch(r.Pattern, origin)
+}
+
+// globMatch は "*" のみをワイルドカードとして扱う単純な glob マッチャー。
+func globMatch(pattern, s string) bool {
+	if pattern == "*" {
+		return true
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i := 1; i < len(parts)-1; i++ {
+		idx := strings.Index(s, parts[i])
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(parts[i]):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// corsRouteConfig は 1 ルート（またはデフォルト）分の CORS 設定。
+type corsRouteConfig struct {
+	Path             string   `yaml:"path"` // 空文字列はデフォルト（全ルート）設定
+	Origins          []string `yaml:"origins"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	MaxAge           int      `yaml:"maxAge"` // Access-Control-Max-Age（秒）
+}
+
+type corsFileConfig struct {
+	Routes []corsRouteConfig `yaml:"routes"`
+}
+
+// corsConfig はパース済み・コンパイル済みの CORS 設定。
+type corsConfig struct {
+	routes []compiledCorsRoute
+}
+
+type compiledCorsRoute struct {
+	pathPrefix       string
+	rules            []*corsOriginRule
+	allowCredentials bool
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	maxAge           string
+}
+
+// corsConfigPath は cors.yaml の既定パス。環境変数 PB_CORS_CONFIG で上書き可能。
+func corsConfigPath() string {
+	if p := os.Getenv("PB_CORS_CONFIG"); p != "" {
+		return p
+	}
+	return filepath.Join("pb_data", "cors.yaml")
+}
+
+// loadCorsConfig は設定ファイルを読み込み、glob/regex をコンパイルして返す。
+// ファイルが存在しない場合は空設定（= 何も許可しない）を返す。
+func loadCorsConfig(path string) (*corsConfig, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &corsConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cors: failed to read %s: %w", path, err)
+	}
+
+	var file corsFileConfig
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("cors: failed to parse %s: %w", path, err)
+	}
+
+	cfg := &corsConfig{}
+	for _, rc := range file.Routes {
+		compiled := compiledCorsRoute{
+			pathPrefix:       rc.Path,
+			allowCredentials: rc.AllowCredentials,
+			allowMethods:     strings.Join(rc.AllowMethods, ", "),
+			allowHeaders:     strings.Join(rc.AllowHeaders, ", "),
+			exposeHeaders:    strings.Join(rc.ExposeHeaders, ", "),
+			maxAge:           fmt.Sprintf("%d", rc.MaxAge),
+		}
+		for _, o := range rc.Origins {
+			rule := &corsOriginRule{Pattern: o}
+			if err := rule.compile(); err != nil {
+				return nil, err
+			}
+			compiled.rules = append(compiled.rules, rule)
+		}
+		cfg.routes = append(cfg.routes, compiled)
+	}
+
+	return cfg, nil
+}
+
+// matchRoute は与えられたリクエストパスに最も一致するルート設定を返す。
+// pathPrefix が最長一致するものを優先し、空文字列（デフォルト）は最後にフォールバックする。
+func (c *corsConfig) matchRoute(path string) *compiledCorsRoute {
+	var best *compiledCorsRoute
+	for i := range c.routes {
+		rt := &c.routes[i]
+		if rt.pathPrefix == "" {
+			if best == nil {
+				best = rt
+			}
+			continue
+		}
+		if strings.HasPrefix(path, rt.pathPrefix) {
+			if best == nil || len(rt.pathPrefix) > len(best.pathPrefix) {
+				best = rt
+			}
+		}
+	}
+	return best
+}
+
+// corsManager は稼働中の CORS 設定を保持し、`cors reload` から
+// ホットリロードできるようにするためのラッパー。
+type corsManager struct {
+	app      core.App
+	path     string
+	cfg      atomic.Pointer[corsConfig]
+	reloadMu sync.Mutex
+}
+
+func newCorsManager(app core.App) *corsManager {
+	return &corsManager{app: app, path: corsConfigPath()}
+}
+
+func (m *corsManager) reload() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	cfg, err := loadCorsConfig(m.path)
+	if err != nil {
+		m.app.Logger().Error("cors: config reload failed", "path", m.path, "error", err)
+		return err
+	}
+	m.cfg.Store(cfg)
+	m.app.Logger().Info("cors: config (re)loaded", "path", m.path, "routes", len(cfg.routes))
+	return nil
+}
+
+func (m *corsManager) current() *corsConfig {
+	cfg := m.cfg.Load()
+	if cfg == nil {
+		return &corsConfig{}
+	}
+	return cfg
+}
+
+// writePidFile records the running process's PID so that a later `cors
+// reload` CLI invocation (a separate OS process) can signal it.
+func (m *corsManager) writePidFile() error {
+	return os.WriteFile(corsPidFilePath(), []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+func (m *corsManager) removePidFile() {
+	if err := os.Remove(corsPidFilePath()); err != nil && !os.IsNotExist(err) {
+		m.app.Logger().Error("cors: failed to remove pid file", "error", err)
+	}
+}
+
+// listenForReloadSignal hot-reloads the config in this already-running
+// process whenever it receives SIGHUP, until ctx is cancelled. This is the
+// actual IPC mechanism behind `cors reload` - a CLI invocation is a brand
+// new process with its own corsManager, so it must signal this one instead
+// of reloading its own throwaway copy.
+func (m *corsManager) listenForReloadSignal(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if err := m.reload(); err != nil {
+				m.app.Logger().Error("cors: SIGHUP reload failed", "error", err)
+			}
+		}
+	}
+}
+
+// middleware は apis.DefaultCorsMiddlewareId の置き換えとして登録する
+// core.ServeEvent 用ミドルウェアを返す。
+func (m *corsManager) middleware() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		origin := e.Request.Header.Get("Origin")
+		if origin == "" {
+			return e.Next()
+		}
+
+		route := m.current().matchRoute(e.Request.URL.Path)
+		if route == nil {
+			return e.Next()
+		}
+
+		allowed := false
+		for _, rule := range route.rules {
+			if rule.matches(origin) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return e.Next()
+		}
+
+		headers := e.Response.Header()
+		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Vary", "Origin")
+		if route.allowCredentials {
+			headers.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if e.Request.Method == http.MethodOptions {
+			if route.allowMethods != "" {
+				headers.Set("Access-Control-Allow-Methods", route.allowMethods)
+			}
+			if route.allowHeaders != "" {
+				headers.Set("Access-Control-Allow-Headers", route.allowHeaders)
+			}
+			if route.maxAge != "" && route.maxAge != "0" {
+				headers.Set("Access-Control-Max-Age", route.maxAge)
+			}
+			return e.NoContent(http.StatusNoContent)
+		}
+		if route.exposeHeaders != "" {
+			headers.Set("Access-Control-Expose-Headers", route.exposeHeaders)
+		}
+		return e.Next()
+	}
+}
+
+// registerCorsReloadCmd adds a `cors reload` subcommand to rootCmd.
+// It runs as a separate OS process from the running `serve` process, so it
+// cannot mutate that process's in-memory config directly - instead it reads
+// the PID the running server recorded in corsPidFilePath() and sends it
+// SIGHUP, which the running process's corsManager.listenForReloadSignal
+// handles by re-reading cors.yaml in place.
+//
+// rootCmd is taken explicitly rather than derived from app because
+// *pocketbase.PocketBase.RootCmd is a field of the concrete struct, not part
+// of the core.App interface.
+func registerCorsReloadCmd(rootCmd *cobra.Command) {
+	cmd := &cobra.Command{
+		Use:   "cors reload",
+		Short: "Hot-reload the CORS configuration of the running server without restarting it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			raw, err := os.ReadFile(corsPidFilePath())
+			if err != nil {
+				return fmt.Errorf("cors: no running server found (%s): %w", corsPidFilePath(), err)
+			}
+
+			pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+			if err != nil {
+				return fmt.Errorf("cors: invalid pid file %s: %w", corsPidFilePath(), err)
+			}
+
+			proc, err := os.FindProcess(pid)
+			if err != nil {
+				return fmt.Errorf("cors: could not find running server process %d: %w", pid, err)
+			}
+			if err := proc.Signal(syscall.SIGHUP); err != nil {
+				return fmt.Errorf("cors: failed to signal running server (pid %d): %w", pid, err)
+			}
+
+			cmd.Println("cors: sent reload signal to running server (pid", pid, ")")
+			return nil
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
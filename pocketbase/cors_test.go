@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://anything.example.com", true},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://app.example.org", false},
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "https://other.example.com", false},
+		{"https://*-preview.example.com", "https://pr-42-preview.example.com", true},
+		{"https://*-preview.example.com", "https://pr-42-prod.example.com", false},
+	}
+
+	for _, c := range cases {
+		if got := globMatch(c.pattern, c.origin); got != c.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", c.pattern, c.origin, got, c.want)
+		}
+	}
+}
+
+func TestCorsOriginRuleMatchesRegex(t *testing.T) {
+	rule := &corsOriginRule{Pattern: `^https://pr-\d+\.preview\.example\.com$`}
+	if err := rule.compile(); err != nil {
+		t.Fatalf("compile() error = %v", err)
+	}
+
+	if !rule.matches("https://pr-123.preview.example.com") {
+		t.Error("expected regex rule to match a valid PR preview origin")
+	}
+	if rule.matches("https://pr-abc.preview.example.com") {
+		t.Error("expected regex rule to reject a non-numeric PR id")
+	}
+}
+
+func TestCorsOriginRuleInvalidRegex(t *testing.T) {
+	rule := &corsOriginRule{Pattern: "^("}
+	if err := rule.compile(); err == nil {
+		t.Error("expected compile() to fail for an invalid regex pattern")
+	}
+}
+
+func TestCorsConfigMatchRoute(t *testing.T) {
+	cfg := &corsConfig{
+		routes: []compiledCorsRoute{
+			{pathPrefix: "", allowMethods: "GET"},
+			{pathPrefix: "/api/", allowMethods: "GET, POST"},
+			{pathPrefix: "/api/admin/", allowMethods: "GET, POST, DELETE"},
+		},
+	}
+
+	cases := []struct {
+		path         string
+		wantMethods  string
+		wantNoRoute  bool
+	}{
+		{"/api/admin/users", "GET, POST, DELETE", false},
+		{"/api/collections", "GET, POST", false},
+		{"/static/logo.png", "GET", false},
+	}
+
+	for _, c := range cases {
+		route := cfg.matchRoute(c.path)
+		if c.wantNoRoute {
+			if route != nil {
+				t.Errorf("matchRoute(%q) = %+v, want nil", c.path, route)
+			}
+			continue
+		}
+		if route == nil {
+			t.Fatalf("matchRoute(%q) = nil, want a route with methods %q", c.path, c.wantMethods)
+		}
+		if route.allowMethods != c.wantMethods {
+			t.Errorf("matchRoute(%q).allowMethods = %q, want %q (longest prefix should win)", c.path, route.allowMethods, c.wantMethods)
+		}
+	}
+}
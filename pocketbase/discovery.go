@@ -0,0 +1,196 @@
+// discovery.go
+// PB_DISCOVERY_BACKEND=consul|etcd が設定されている場合、起動中の
+// PocketBase インスタンスをサービスレジストリに登録し、TTL ベースの
+// ハートビートで生存を知らせ、正常終了時に登録解除する。サービスメッシュの
+// サイドカーなしで PocketBase をロードバランサ配下に置けるようにする。
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// discoveryHeartbeatInterval は TTL チェックを更新する間隔。
+// TTL 自体より十分短く保ち、ネットワークの瞬断でレジストリから
+// 除外されないようにする。
+const discoveryHeartbeatInterval = 10 * time.Second
+
+// discoveryTTL はレジストリ側の健全性チェック TTL。
+const discoveryTTL = 30 * time.Second
+
+// Provider はサービスレジストリへの登録/解除/生存通知を抽象化する。
+type Provider interface {
+	// Register は現在のインスタンスをレジストリに登録する。
+	Register(ctx context.Context) error
+	// Heartbeat はレジストリに生存を通知する（TTL チェックの更新など）。
+	Heartbeat(ctx context.Context) error
+	// Deregister はレジストリから登録を解除する。
+	Deregister(ctx context.Context) error
+}
+
+// discoveryInstance はレジストリに登録するインスタンス情報。
+type discoveryInstance struct {
+	ServiceName string
+	InstanceID  string
+	Host        string
+	Port        int
+	Tags        []string
+	HealthPath  string // 例: /api/health
+}
+
+func newDiscoveryInstanceFromEnv() discoveryInstance {
+	host := os.Getenv("PB_DISCOVERY_HOST")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	port, _ := strconv.Atoi(os.Getenv("PB_DISCOVERY_PORT"))
+	if port == 0 {
+		port = 8090
+	}
+	instanceID := os.Getenv("PB_DISCOVERY_INSTANCE_ID")
+	if instanceID == "" {
+		instanceID = fmt.Sprintf("pocketbase-%d", os.Getpid())
+	}
+
+	return discoveryInstance{
+		ServiceName: "pocketbase",
+		InstanceID:  instanceID,
+		Host:        host,
+		Port:        port,
+		Tags:        []string{"miniapp-template"},
+		HealthPath:  "/api/health",
+	}
+}
+
+// newDiscoveryProvider は PB_DISCOVERY_BACKEND の値に応じた Provider を
+// 生成する。未設定の場合は nil, false を返す。値は設定されているが
+// どの backend にも一致しない場合は、サイレントに discovery が無効化
+// されたように見えてしまわないよう警告をログに残した上で nil, false を返す。
+func newDiscoveryProvider(app core.App, inst discoveryInstance) (Provider, bool) {
+	backend := os.Getenv("PB_DISCOVERY_BACKEND")
+
+	switch backend {
+	case "":
+		return nil, false
+	case "consul":
+		addr := os.Getenv("PB_DISCOVERY_CONSUL_ADDR")
+		if addr == "" {
+			addr = "http://127.0.0.1:8500"
+		}
+		return &consulProvider{app: app, addr: addr, inst: inst}, true
+	case "etcd":
+		addr := os.Getenv("PB_DISCOVERY_ETCD_ADDR")
+		if addr == "" {
+			addr = "http://127.0.0.1:2379"
+		}
+		return &etcdProvider{app: app, addr: addr, inst: inst}, true
+	case "nats":
+		// nats はレジストリに HTTP/JSON API を持たず（consul/etcd と異なり
+		// 素の NATS プロトコルは TCP 上の独自バイナリプロトコルで、外部 SDK
+		// なしには実装できない）、このパッケージの「外部 SDK に依存しない」
+		// 方針の範囲では実装できない。サイレントに無効化されたように見せず、
+		// 未対応であることを明示してログに残す。
+		app.Logger().Error("discovery: PB_DISCOVERY_BACKEND=nats is not yet implemented, discovery is disabled", "backend", backend)
+		return nil, false
+	default:
+		app.Logger().Error("discovery: unrecognized PB_DISCOVERY_BACKEND, discovery is disabled", "backend", backend)
+		return nil, false
+	}
+}
+
+//------------------------------------------------------------------------
+// Consul
+//------------------------------------------------------------------------
+
+type consulProvider struct {
+	app  core.App
+	addr string
+	inst discoveryInstance
+}
+
+func (p *consulProvider) Register(ctx context.Context) error {
+	body := fmt.Sprintf(`{
+		"ID": %q,
+		"Name": %q,
+		"Address": %q,
+		"Port": %d,
+		"Tags": %s,
+		"Check": {"TTL": %q, "DeregisterCriticalServiceAfter": "5m"}
+	}`, p.inst.InstanceID, p.inst.ServiceName, p.inst.Host, p.inst.Port, jsonStringArray(p.inst.Tags), discoveryTTL.String())
+
+	return doJSONRequest(ctx, http.MethodPut, p.addr+"/v1/agent/service/register", body)
+}
+
+func (p *consulProvider) Heartbeat(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1/agent/check/pass/service:%s", p.addr, p.inst.InstanceID)
+	return doJSONRequest(ctx, http.MethodPut, url, "")
+}
+
+func (p *consulProvider) Deregister(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1/agent/service/deregister/%s", p.addr, p.inst.InstanceID)
+	return doJSONRequest(ctx, http.MethodPut, url, "")
+}
+
+//------------------------------------------------------------------------
+// etcd (v3 JSON/gRPC-gateway API, lease ベース)
+//------------------------------------------------------------------------
+
+type etcdProvider struct {
+	app     core.App
+	addr    string
+	inst    discoveryInstance
+	leaseID string
+}
+
+func (p *etcdProvider) Register(ctx context.Context) error {
+	grantBody := fmt.Sprintf(`{"TTL": %d}`, int64(discoveryTTL.Seconds()))
+	leaseID, err := postJSONForLeaseID(ctx, p.addr+"/v3/lease/grant", grantBody)
+	if err != nil {
+		return fmt.Errorf("discovery: etcd lease grant failed: %w", err)
+	}
+	p.leaseID = leaseID
+
+	key := fmt.Sprintf("services/%s/%s", p.inst.ServiceName, p.inst.InstanceID)
+	value := fmt.Sprintf(`{"host":%q,"port":%d,"tags":%s}`, p.inst.Host, p.inst.Port, jsonStringArray(p.inst.Tags))
+	putBody := fmt.Sprintf(`{"key":%q,"value":%q,"lease":%q}`, b64(key), b64(value), p.leaseID)
+
+	return doJSONRequest(ctx, http.MethodPost, p.addr+"/v3/kv/put", putBody)
+}
+
+func (p *etcdProvider) Heartbeat(ctx context.Context) error {
+	body := fmt.Sprintf(`{"ID": %q}`, p.leaseID)
+	return doJSONRequest(ctx, http.MethodPost, p.addr+"/v3/lease/keepalive", body)
+}
+
+func (p *etcdProvider) Deregister(ctx context.Context) error {
+	body := fmt.Sprintf(`{"ID": %q}`, p.leaseID)
+	return doJSONRequest(ctx, http.MethodPost, p.addr+"/v3/lease/revoke", body)
+}
+
+//------------------------------------------------------------------------
+// runDiscoveryHeartbeat / wiring
+//------------------------------------------------------------------------
+
+// runDiscoveryHeartbeat はバックグラウンドで Provider.Heartbeat を定期的に
+// 呼び出す。ctx がキャンセルされたら停止する。
+func runDiscoveryHeartbeat(ctx context.Context, app core.App, provider Provider) {
+	ticker := time.NewTicker(discoveryHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := provider.Heartbeat(ctx); err != nil {
+				app.Logger().Error("discovery: heartbeat failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// discovery_http.go
+// discovery.go の Consul/etcd プロバイダが使う最小限の HTTP ヘルパー。
+// 外部 SDK に依存せず、各レジストリの HTTP/JSON API を直接叩く。
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var discoveryHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+func doJSONRequest(ctx context.Context, method, url, body string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discoveryHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+func postJSONForLeaseID(ctx context.Context, url, body string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discoveryHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var parsed struct {
+		ID string `json:"ID"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+	return parsed.ID, nil
+}
+
+func jsonStringArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		b, _ := json.Marshal(item)
+		quoted[i] = string(b)
+	}
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,252 @@
+// jsvm_limits.go
+// pb_hooks 配下の JS フックは信頼できないコードとして扱い、require で読み込める
+// モジュールをホワイトリストに制限し、VM のコールスタック長とプロセス全体の
+// ヒープにソフト上限を課す。さらに OnBootstrap/OnTerminate のような、起動・
+// 終了時に一度だけ同期的に実行される一部のライフサイクルフックに限り、壁時計
+// タイムアウトを課して暴走スクリプトがサーバの起動・終了を永久にブロックしな
+// いようにする（リクエスト単位のフック全般には適用しない。理由は
+// wrapAppHooksWithWatchdog のコメントを参照）。
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/plugins/jsvm"
+)
+
+// jsvmMaxCallStackSize は goja.Runtime.SetMaxCallStackSize に渡す上限。
+// 暴走した再帰呼び出しが OS スタックを使い果たす前に goja 自身のエラーとして
+// 止めるための、VM ごとの安全弁。
+const jsvmMaxCallStackSize = 512
+
+// jsvmLimits は pb_hooks 実行に適用するリソース上限。
+type jsvmLimits struct {
+	MaxWallTime    time.Duration // フック実行の壁時計タイムアウト
+	MaxHeapBytes   int64         // プロセス全体のソフトヒープ上限（後述）
+	AllowedModules []string      // pb_hooks から require 可能な Go/JS モジュール名
+}
+
+func defaultJSVMLimits() jsvmLimits {
+	limits := jsvmLimits{
+		MaxWallTime:    5 * time.Second,
+		MaxHeapBytes:   64 << 20, // 64MiB
+		AllowedModules: []string{},
+	}
+
+	if v := os.Getenv("PB_JSVM_MAX_WALL_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil {
+			limits.MaxWallTime = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("PB_JSVM_MAX_HEAP_BYTES"); v != "" {
+		if b, err := strconv.ParseInt(v, 10, 64); err == nil {
+			limits.MaxHeapBytes = b
+		}
+	}
+	if v := os.Getenv("PB_JSVM_ALLOWED_MODULES"); v != "" {
+		limits.AllowedModules = strings.Split(v, ",")
+	}
+
+	return limits
+}
+
+func (l jsvmLimits) isModuleAllowed(name string) bool {
+	for _, allowed := range l.AllowedModules {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsvmWatchdogErr is the error a watchdog-guarded hook returns once it has
+// run past its wall time limit.
+type jsvmWatchdogErr struct {
+	hookPath string
+	limit    time.Duration
+}
+
+func (e *jsvmWatchdogErr) Error() string {
+	return fmt.Sprintf("jsvm: hook %q exceeded wall time limit of %s", e.hookPath, e.limit)
+}
+
+var applyProcessMemoryLimitOnce sync.Once
+
+// applyProcessMemoryLimit sets the Go runtime's soft memory limit
+// (runtime/debug.SetMemoryLimit) once for the whole process.
+//
+// goja has no API for capping a single Runtime's heap (there is no
+// SetMemoryLimit or equivalent byte-accounting hook on *goja.Runtime), so a
+// genuine per-VM "max heap allocation" as originally envisioned cannot be
+// built on top of it. The soft memory limit is the closest real lever
+// available: it makes the GC collect more aggressively as the process
+// approaches the configured ceiling instead of letting an unbounded pb_hooks
+// script OOM the whole server. It is necessarily process-wide, not
+// per-script.
+func applyProcessMemoryLimit(limits jsvmLimits) {
+	applyProcessMemoryLimitOnce.Do(func() {
+		if limits.MaxHeapBytes > 0 {
+			debug.SetMemoryLimit(limits.MaxHeapBytes)
+		}
+	})
+}
+
+// guardVM caps a VM's call stack and restricts which modules it can
+// require(). Called from MustRegisterJSVMWithLimits's OnInit for every VM
+// jsvm creates (the loader and each pooled executor).
+func guardVM(vm *goja.Runtime, limits jsvmLimits) {
+	vm.SetMaxCallStackSize(jsvmMaxCallStackSize)
+	guardRequire(vm, limits)
+}
+
+// guardRequire は jsvm/goja_nodejs が既に設定した require 実装を捕まえて
+// から差し替え、許可されていないモジュール名だけを拒否する。未許可の場合に
+// 実装そのものを空振りさせてしまうと全ての require が壊れるため、許可された
+// モジュールは必ず元の require に委譲する。
+func guardRequire(vm *goja.Runtime, limits jsvmLimits) {
+	original, ok := goja.AssertFunction(vm.Get("require"))
+	if !ok {
+		return // jsvm がまだ require を公開していない場合は何もしない
+	}
+
+	vm.Set("require", func(call goja.FunctionCall) goja.Value {
+		name := call.Argument(0).String()
+		if !limits.isModuleAllowed(name) {
+			panic(vm.ToValue(fmt.Sprintf("jsvm: module %q is not in the allowlist", name)))
+		}
+		result, err := original(goja.Undefined(), call.Arguments...)
+		if err != nil {
+			panic(vm.ToValue(err.Error()))
+		}
+		return result
+	})
+}
+
+// jsvmWatchdogHooks is an allowlist, not a blocklist: only core.App hooks
+// that fire exactly once, synchronously, outside of any in-flight HTTP
+// request are wrapped with the watchdog below.
+//
+// Every other "On*" hook (record/collection CRUD, request hooks, mailer,
+// realtime, ...) can run in the middle of handling a live HTTP request, and
+// the watchdog's only real lever - giving up on Next() after a timeout
+// while its goroutine keeps running in the background (see
+// wrapAppHooksWithWatchdog) - would then race that abandoned goroutine
+// against whatever the "hook timed out" error path does next, with both
+// potentially touching the same in-flight request/response or the same
+// now-finished DB transaction. That's a worse failure mode (corrupted
+// responses, use-after-commit) than the hang it's meant to prevent, so
+// those hooks are deliberately left unguarded here: a stuck request-scoped
+// hook still pins that one request's goroutine, same as before this file
+// existed, but it doesn't corrupt anything else.
+var jsvmWatchdogHooks = []string{"OnBootstrap", "OnTerminate"}
+
+// wrapAppHooksWithWatchdog registers a BindFunc as the *first* handler of
+// each hook named in jsvmWatchdogHooks, using the same reflection approach
+// plugins/jsvm's own hooksBinds uses internally to look up hook getters on
+// core.App (see binds.go). Each wrapper arms a wall-time watchdog, calls the
+// rest of the chain (which is where jsvm's own JS-bound handler runs, once
+// jsvm.MustRegister has added it), and returns an error if the chain
+// doesn't finish in time - so a pb_hooks script stuck in onBootstrap/
+// onTerminate can no longer hang the server's startup or shutdown forever.
+//
+// This must run *before* jsvm.MustRegister, because hook.Hook runs its
+// bound handlers in registration order - registering first is what lets
+// this wrap the JS-bound handler added later.
+//
+// A wrapper around jsvm.Config.OnInit cannot do this: OnInit fires while
+// constructing a goja.Runtime, before hooksBinds/cronBinds/routerBinds have
+// registered anything on it (see plugins/jsvm/jsvm.go, sharedBinds runs
+// OnInit, then hooksBinds/cronBinds/routerBinds run after), so there is no
+// JS global or $app method yet to intercept from inside OnInit. The
+// core.App hook chain itself is the only point where "a handler for this
+// event is about to run" is observable from outside the jsvm package.
+func wrapAppHooksWithWatchdog(app core.App, limits jsvmLimits) {
+	appType := reflect.TypeOf(app)
+	appValue := reflect.ValueOf(app)
+
+	for i := 0; i < appType.NumMethod(); i++ {
+		method := appType.Method(i)
+		if !slices.Contains(jsvmWatchdogHooks, method.Name) {
+			continue
+		}
+
+		results := appValue.MethodByName(method.Name).Call(nil)
+		if len(results) != 1 {
+			continue
+		}
+
+		bindFunc := results[0].MethodByName("BindFunc")
+		if !bindFunc.IsValid() {
+			continue
+		}
+
+		hookName := method.Name
+		handlerType := bindFunc.Type().In(0)
+
+		handler := reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+			var resultErr error
+
+			next := args[0].MethodByName("Next")
+			if !next.IsValid() {
+				return []reflect.Value{reflect.ValueOf(&resultErr).Elem()}
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				nextResults := next.Call(nil)
+				if len(nextResults) == 1 && !nextResults[0].IsNil() {
+					done <- nextResults[0].Interface().(error)
+					return
+				}
+				done <- nil
+			}()
+
+			select {
+			case err := <-done:
+				resultErr = err
+			case <-time.After(limits.MaxWallTime):
+				watchdogErr := &jsvmWatchdogErr{hookPath: hookName, limit: limits.MaxWallTime}
+				app.Logger().Error("jsvm: hook exceeded wall time limit, returning early", "hook", hookName, "error", watchdogErr.Error())
+				// There is no public API to forcibly preempt a goja.Runtime
+				// mid-script from outside plugins/jsvm (that requires a
+				// reference to the specific executor the JS handler is
+				// running on, which jsvm's internal vmsPool never exposes),
+				// so the goroutine above is left to finish or abandon on its
+				// own. This bounds how long startup/shutdown waits on the
+				// hook chain, not the CPU actually spent by a runaway script.
+				resultErr = watchdogErr
+			}
+
+			return []reflect.Value{reflect.ValueOf(&resultErr).Elem()}
+		})
+
+		bindFunc.Call([]reflect.Value{handler})
+	}
+}
+
+// MustRegisterJSVMWithLimits は jsvm.MustRegister をラップし、登録前に
+// hook チェーンへ壁時計タイムアウトの監視を差し込み、生成される各 VM に
+// コールスタック上限・require のホワイトリストを適用する。
+func MustRegisterJSVMWithLimits(app core.App, hooksWatch bool) {
+	limits := defaultJSVMLimits()
+
+	applyProcessMemoryLimit(limits)
+	wrapAppHooksWithWatchdog(app, limits)
+
+	jsvm.MustRegister(app, jsvm.Config{
+		HooksWatch: hooksWatch,
+		OnInit: func(vm *goja.Runtime) {
+			guardVM(vm, limits)
+		},
+	})
+}
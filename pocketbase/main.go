@@ -1,15 +1,17 @@
 // main.go
-// PocketBase v0.29.x – 既定の CORS ミドルウェアを解除し、
-// JSVM + migratecmd プラグインを登録する最小構成。
+// PocketBase v0.39.x – カスタム CORS、JSVM（リソース制限付き）、
+// migratecmd、モジュール単位の Configurator マイグレーション、
+// releasecmd、任意のサービスディスカバリを束ねるアプリ起動エントリポイント。
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/plugins/jsvm"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 )
 
@@ -18,24 +20,77 @@ func main() {
 	app := pocketbase.New()
 
 	//--------------------------------------------------------------------
-	// 1) 既定 CORS ミドルウェアを解除（カスタム化したい場合）
+	// 1) 既定 CORS ミドルウェアを解除し、pb_data/cors.yaml ベースの
+	//    カスタム CORS サブシステムに差し替える
 	//--------------------------------------------------------------------
+	corsManager := newCorsManager(app)
+	registerCorsReloadCmd(app.RootCmd)
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		// apis.DefaultCorsMiddlewareId は v0.29 現行定数名
 		se.Router.Unbind(apis.DefaultCorsMiddlewareId)
+
+		if err := corsManager.reload(); err != nil {
+			// 起動時の設定ミスはログに残しつつ、空設定で起動は継続する
+			app.Logger().Error("cors: starting with empty config due to load error", "error", err)
+		}
+		se.Router.BindFunc(corsManager.middleware())
+
+		// `cors reload` (a separate CLI invocation) signals this running
+		// process via SIGHUP instead of mutating its own throwaway config.
+		if err := corsManager.writePidFile(); err != nil {
+			app.Logger().Error("cors: failed to write pid file", "error", err)
+		}
+		reloadCtx, cancelReloadListener := context.WithCancel(context.Background())
+		go corsManager.listenForReloadSignal(reloadCtx)
+
+		app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+			cancelReloadListener()
+			corsManager.removePidFile()
+			return e.Next()
+		})
+
+		//----------------------------------------------------------------
+		// サービスディスカバリ: PB_DISCOVERY_BACKEND=consul|etcd が設定
+		// されている場合のみ、レジストリへの登録とハートビートを開始する
+		//----------------------------------------------------------------
+		se.Router.GET("/api/health", func(re *core.RequestEvent) error {
+			return re.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		})
+
+		inst := newDiscoveryInstanceFromEnv()
+		if provider, enabled := newDiscoveryProvider(app, inst); enabled {
+			discoveryCtx, cancelDiscovery := context.WithCancel(context.Background())
+
+			if err := provider.Register(discoveryCtx); err != nil {
+				app.Logger().Error("discovery: registration failed", "error", err)
+			} else {
+				go runDiscoveryHeartbeat(discoveryCtx, app, provider)
+			}
+
+			app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+				cancelDiscovery()
+				if err := provider.Deregister(context.Background()); err != nil {
+					app.Logger().Error("discovery: deregistration failed", "error", err)
+				}
+				return e.Next()
+			})
+		}
+
 		return se.Next()
 	})
 
 	//--------------------------------------------------------------------
 	// 2) JSVM プラグイン登録
-	//    第 2 引数に jsvm.Config が必須（v0.23+）
+	//    コールスタック上限・プロセス全体のソフトヒープ上限・require の
+	//    モジュールホワイトリストを適用し、OnBootstrap/OnTerminate に限り
+	//    壁時計タイムアウトを課すラッパーで登録する。リクエスト単位の
+	//    フックにはタイムアウトがなく、停止したスクリプトはそのリクエストの
+	//    goroutine を専有し続ける
 	//--------------------------------------------------------------------
 	// isDev := strings.HasPrefix(os.Args[0], os.TempDir()) // go run 時は一時パスに置かれる
 	isDev := true
-	jsvm.MustRegister(app, jsvm.Config{
-		// pb_hooks / pb_migrations はデフォルトパスを利用
-		HooksWatch: isDev, // 開発時のみホットリロード
-	})
+	MustRegisterJSVMWithLimits(app, isDev)
 
 	//--------------------------------------------------------------------
 	// 3) migratecmd プラグイン登録
@@ -47,7 +102,38 @@ func main() {
 	})
 
 	//--------------------------------------------------------------------
-	// 4) サーバ起動
+	// 4) モジュール単位のバージョン管理マイグレーション（Configurator）
+	//    migratecmd のファイルベース DB マイグレーションとは別に、機能
+	//    領域ごとの ConsensusVersion を app.Start() 時に適用する
+	//--------------------------------------------------------------------
+	configurator := NewConfigurator()
+	registerAppMigrations(configurator)
+	registerMigrateListModulesCmd(app, app.RootCmd, configurator)
+
+	app.OnBootstrap().BindFunc(func(e *core.BootstrapEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return configurator.Run(app)
+	})
+
+	//--------------------------------------------------------------------
+	// 5) releasecmd: DB 以外の外部リソースを versioned リリースとして
+	//    migratecmd と同じパイプラインで適用する姉妹プラグイン
+	//--------------------------------------------------------------------
+	releaseRegistry := NewReleaseRegistry()
+	registerAppReleases(releaseRegistry)
+	registerReleaseCmd(app, app.RootCmd, releaseRegistry)
+
+	app.OnBootstrap().BindFunc(func(e *core.BootstrapEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return releaseRegistry.Apply(app, false)
+	})
+
+	//--------------------------------------------------------------------
+	// 6) サーバ起動
 	//--------------------------------------------------------------------
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,225 @@
+// migrations_configurator.go
+// Cosmos SDK の in-place store migration に着想を得た、モジュール単位の
+// バージョン管理レイヤー。migratecmd（ファイルベースの DB マイグレーション）
+// とは別に、機能領域ごとに ConsensusVersion を持つ「モジュール」を登録し、
+// 起動時に未適用の n → n+1 アップグレードだけを順番に適用する。
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// migrationKeeperCollectionName は適用済みバージョンを永続化するシステム
+// コレクション名。
+const migrationKeeperCollectionName = "_migration_keeper"
+
+// MigrationHandler は 1 つの n → n+1 アップグレード処理。
+type MigrationHandler func(app core.App) error
+
+// migrationStep は登録された 1 ステップ分のアップグレード。
+type migrationStep struct {
+	fromVersion uint64
+	handler     MigrationHandler
+}
+
+// Configurator はモジュール名ごとに登録されたマイグレーションステップを
+// 保持し、永続化された適用済みバージョンとの差分だけを実行する。
+type Configurator struct {
+	steps map[string][]migrationStep
+}
+
+// NewConfigurator は空の Configurator を生成する。
+func NewConfigurator() *Configurator {
+	return &Configurator{steps: map[string][]migrationStep{}}
+}
+
+// RegisterMigration はモジュール moduleName に対し、fromVersion から
+// fromVersion+1 へアップグレードする handler を登録する。
+func (c *Configurator) RegisterMigration(moduleName string, fromVersion uint64, handler MigrationHandler) {
+	c.steps[moduleName] = append(c.steps[moduleName], migrationStep{
+		fromVersion: fromVersion,
+		handler:     handler,
+	})
+}
+
+// targetVersion はモジュールに登録された最大の fromVersion+1、つまり
+// このプロセスが到達させたい ConsensusVersion を返す。
+func (c *Configurator) targetVersion(moduleName string) uint64 {
+	var target uint64
+	for _, s := range c.steps[moduleName] {
+		if s.fromVersion+1 > target {
+			target = s.fromVersion + 1
+		}
+	}
+	return target
+}
+
+// moduleNames は登録済みモジュール名をソート済みで返す（実行順を決定論的にする）。
+func (c *Configurator) moduleNames() []string {
+	names := make([]string, 0, len(c.steps))
+	for name := range c.steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ensureMigrationKeeperCollection は適用済みバージョンを記録するための
+// システムコレクションが存在しなければ作成する。
+func ensureMigrationKeeperCollection(app core.App) error {
+	if _, err := app.FindCollectionByNameOrId(migrationKeeperCollectionName); err == nil {
+		return nil
+	}
+
+	collection := core.NewBaseCollection(migrationKeeperCollectionName)
+	collection.System = true
+	collection.Fields.Add(
+		&core.TextField{Name: "module", Required: true},
+		&core.NumberField{Name: "version", Required: true},
+	)
+	collection.AddIndex("idx_migration_keeper_module", true, "module", "")
+
+	return app.Save(collection)
+}
+
+// loadAppliedVersion はモジュールの現在の適用済みバージョンを返す。
+// レコードが存在しない場合は 0（未適用）を返すが、クエリ自体が失敗した
+// 場合はそれを未適用と取り違えず呼び出し元にエラーとして伝える。
+func loadAppliedVersion(app core.App, moduleName string) (uint64, *core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		migrationKeeperCollectionName,
+		"module = {:module}",
+		map[string]any{"module": moduleName},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, nil // レコードなし = 未適用
+		}
+		return 0, nil, fmt.Errorf("migrations: failed to load applied version for module %q: %w", moduleName, err)
+	}
+	return uint64(record.GetInt("version")), record, nil
+}
+
+// Run は全モジュールについて、現在の適用済みバージョンから登録済みの
+// ConsensusVersion までのアップグレードを順番に、モジュールごと 1
+// トランザクションで実行する。失敗したモジュールはロールバックされ、Run は
+// そのエラーを直ちに返す（以降のモジュールは実行されず、それ以前のモジュールの
+// アップグレードはコミット済みのまま残る）。
+func (c *Configurator) Run(app core.App) error {
+	if err := ensureMigrationKeeperCollection(app); err != nil {
+		return fmt.Errorf("migrations: failed to ensure keeper collection: %w", err)
+	}
+
+	for _, moduleName := range c.moduleNames() {
+		steps := c.steps[moduleName]
+		sort.Slice(steps, func(i, j int) bool { return steps[i].fromVersion < steps[j].fromVersion })
+
+		current, record, err := loadAppliedVersion(app, moduleName)
+		if err != nil {
+			return err
+		}
+
+		target := c.targetVersion(moduleName)
+		if current >= target {
+			continue
+		}
+
+		err = app.RunInTransaction(func(txApp core.App) error {
+			applied := current
+			for _, step := range steps {
+				if step.fromVersion != applied {
+					continue
+				}
+				if err := step.handler(txApp); err != nil {
+					return fmt.Errorf("migrations: module %q step %d->%d failed: %w", moduleName, step.fromVersion, step.fromVersion+1, err)
+				}
+				applied = step.fromVersion + 1
+			}
+
+			// A gap in the registered steps (e.g. 0->1 and 2->3 but no
+			// 1->2) must not be persisted as success: applied would stall
+			// below target forever, and every future boot would retry the
+			// same partial, non-advancing sequence with no surfaced error.
+			if applied != target {
+				return fmt.Errorf("migrations: module %q has a gap in its registered steps: reached version %d but target is %d (missing a %d->%d handler)", moduleName, applied, target, applied, applied+1)
+			}
+
+			if record == nil {
+				record = core.NewRecord(mustGetCollection(txApp, migrationKeeperCollectionName))
+				record.Set("module", moduleName)
+			}
+			record.Set("version", applied)
+			return txApp.Save(record)
+		})
+		if err != nil {
+			return err
+		}
+
+		app.Logger().Info("migrations: module upgraded", "module", moduleName, "from", current, "to", target)
+	}
+
+	return nil
+}
+
+func mustGetCollection(app core.App, nameOrId string) *core.Collection {
+	collection, err := app.FindCollectionByNameOrId(nameOrId)
+	if err != nil {
+		panic(err)
+	}
+	return collection
+}
+
+// registerMigrateListModulesCmd adds a `list-modules` subcommand to the
+// existing top-level `migrate` command that migratecmd.MustRegister already
+// registered on rootCmd, so it shows up as `./app migrate list-modules`
+// instead of colliding with it as a second, unreachable top-level "migrate"
+// command (cobra resolves name collisions to whichever command was added
+// first).
+//
+// rootCmd is taken explicitly rather than derived from app because
+// *pocketbase.PocketBase.RootCmd is a field of the concrete struct, not part
+// of the core.App interface.
+func registerMigrateListModulesCmd(app core.App, rootCmd *cobra.Command, configurator *Configurator) {
+	cmd := &cobra.Command{
+		Use:   "list-modules",
+		Short: "List registered migration modules with their current and target ConsensusVersion",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, moduleName := range configurator.moduleNames() {
+				current, _, err := loadAppliedVersion(app, moduleName)
+				if err != nil {
+					return err
+				}
+				target := configurator.targetVersion(moduleName)
+				cmd.Printf("%-30s current=%d target=%d\n", moduleName, current, target)
+			}
+			return nil
+		},
+	}
+
+	if migrateCmd := findSubCommand(rootCmd, "migrate"); migrateCmd != nil {
+		migrateCmd.AddCommand(cmd)
+		return
+	}
+
+	// migratecmd hasn't registered "migrate" yet (unexpected registration
+	// order) - fall back to a standalone top-level command rather than
+	// silently dropping the feature.
+	rootCmd.AddCommand(cmd)
+}
+
+// findSubCommand returns the direct child of root whose Name() matches name,
+// or nil if there is none.
+func findSubCommand(root *cobra.Command, name string) *cobra.Command {
+	for _, c := range root.Commands() {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// releasecmd.go
+// migratecmd が DB スキーマを versioned に管理するのに対し、releasecmd は
+// DB 以外の外部リソース副作用（S3 バケットポリシー、Webhook 登録、シード
+// 管理者、OAuth プロバイダ設定など）を同じ要領で「リリース」として
+// バージョン管理する姉妹プラグイン。
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// releasesCollectionName は適用済みリリースの状態を記録するシステム
+// コレクション名。
+const releasesCollectionName = "_releases"
+
+// ReleaseFunc は 1 つのリリースに対する up/down 処理。
+type ReleaseFunc func(app core.App) error
+
+// Release は DB 以外の外部リソースに対する 1 つの versioned な変更。
+// Checksum は Source（Up/Down の実体を表す文字列。JS リリースならファイル
+// 内容そのもの、Go リリースなら適用内容を表す固定文字列）から算出され、
+// 既に適用済みのリリースが無断で書き換えられていないかを検出する。
+type Release struct {
+	Name      string
+	DependsOn []string
+	Source    string
+	Up        ReleaseFunc
+	Down      ReleaseFunc
+}
+
+func (r *Release) checksum() string {
+	sum := sha256.Sum256([]byte(r.Source))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReleaseRegistry はリリースの登録と、依存関係に基づくトポロジカルソート、
+// 適用/ロールバックを担当する。
+type ReleaseRegistry struct {
+	releases map[string]*Release
+}
+
+// NewReleaseRegistry は空の ReleaseRegistry を生成する。
+func NewReleaseRegistry() *ReleaseRegistry {
+	return &ReleaseRegistry{releases: map[string]*Release{}}
+}
+
+// Register はリリースをレジストリに追加する。
+func (r *ReleaseRegistry) Register(release *Release) {
+	r.releases[release.Name] = release
+}
+
+// sortedReleases は dependsOn に基づきトポロジカルソートした順序で
+// リリースを返す。循環依存はエラーとする。
+func (r *ReleaseRegistry) sortedReleases() ([]*Release, error) {
+	const (
+		white = 0
+		gray  = 1
+		black = 2
+	)
+	state := map[string]int{}
+	var order []*Release
+
+	names := make([]string, 0, len(r.releases))
+	for name := range r.releases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case black:
+			return nil
+		case gray:
+			return fmt.Errorf("releasecmd: circular dependency detected at %q", name)
+		}
+
+		release, ok := r.releases[name]
+		if !ok {
+			return fmt.Errorf("releasecmd: unknown dependency %q", name)
+		}
+
+		state[name] = gray
+		deps := append([]string(nil), release.DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = black
+		order = append(order, release)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// ensureReleasesCollection は _releases システムコレクションが存在しなければ
+// 作成する。
+func ensureReleasesCollection(app core.App) error {
+	if _, err := app.FindCollectionByNameOrId(releasesCollectionName); err == nil {
+		return nil
+	}
+
+	collection := core.NewBaseCollection(releasesCollectionName)
+	collection.System = true
+	collection.Fields.Add(
+		&core.TextField{Name: "name", Required: true},
+		&core.TextField{Name: "checksum", Required: true},
+	)
+	collection.AddIndex("idx_releases_name", true, "name", "")
+
+	return app.Save(collection)
+}
+
+// findReleaseRecord returns the persisted state for name, nil if it has
+// never been applied, or an error if the lookup itself failed (which must
+// not be treated the same as "never applied").
+func findReleaseRecord(app core.App, name string) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		releasesCollectionName,
+		"name = {:name}",
+		map[string]any{"name": name},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // 未適用
+		}
+		return nil, fmt.Errorf("releasecmd: failed to look up release %q: %w", name, err)
+	}
+	return record, nil
+}
+
+// Apply は登録済みリリースを依存関係順に適用する。既に適用済みで
+// checksum が一致するものはスキップする。checksum が食い違う（= 適用後に
+// リリース定義が書き換えられた）場合は force が true でない限りエラーにする。
+// 途中のリリースが失敗した場合、この Apply 呼び出しで既に適用した（それより
+// 前段の、および状態の保存に失敗したリリース自身の）リリースに対して down を
+// 逆順に実行し、補償（コンペンセート）する。
+func (r *ReleaseRegistry) Apply(app core.App, force bool) error {
+	if err := ensureReleasesCollection(app); err != nil {
+		return fmt.Errorf("releasecmd: failed to ensure releases collection: %w", err)
+	}
+
+	ordered, err := r.sortedReleases()
+	if err != nil {
+		return err
+	}
+
+	var applied []*Release
+	rollback := func(cause error) error {
+		for i := len(applied) - 1; i >= 0; i-- {
+			rel := applied[i]
+			if rel.Down == nil {
+				app.Logger().Error("releasecmd: release has no down, cannot be compensated and its state was left in place", "release", rel.Name)
+				continue
+			}
+
+			if downErr := rel.Down(app); downErr != nil {
+				app.Logger().Error("releasecmd: compensating down failed, its _releases state was left in place", "release", rel.Name, "error", downErr)
+				continue
+			}
+
+			// down succeeded: the external resource is gone, so the
+			// _releases record must be cleared too, otherwise the next
+			// Apply() sees a matching checksum and thinks it's still applied.
+			record, err := findReleaseRecord(app, rel.Name)
+			if err != nil {
+				app.Logger().Error("releasecmd: failed to look up release state after rollback", "release", rel.Name, "error", err)
+				continue
+			}
+			if record == nil {
+				continue
+			}
+			if err := app.Delete(record); err != nil {
+				app.Logger().Error("releasecmd: failed to clear release state after rollback", "release", rel.Name, "error", err)
+			}
+		}
+		return cause
+	}
+
+	for _, release := range ordered {
+		record, err := findReleaseRecord(app, release.Name)
+		if err != nil {
+			return err
+		}
+
+		if record != nil {
+			if record.GetString("checksum") == release.checksum() {
+				continue // 変更なし、適用済み
+			}
+			if !force {
+				return fmt.Errorf("releasecmd: release %q has changed since it was applied; rerun with --force to reapply", release.Name)
+			}
+		}
+
+		if err := release.Up(app); err != nil {
+			return rollback(fmt.Errorf("releasecmd: release %q failed: %w", release.Name, err))
+		}
+		applied = append(applied, release)
+
+		if record == nil {
+			record = core.NewRecord(mustGetCollection(app, releasesCollectionName))
+			record.Set("name", release.Name)
+		}
+		record.Set("checksum", release.checksum())
+		if err := app.Save(record); err != nil {
+			return rollback(fmt.Errorf("releasecmd: failed to persist release %q state: %w", release.Name, err))
+		}
+
+		app.Logger().Info("releasecmd: release applied", "release", release.Name)
+	}
+
+	return nil
+}
+
+// registerReleaseCmd は `release apply` サブコマンドを rootCmd に追加する。
+// rootCmd は app から導出せず明示的に受け取る。*pocketbase.PocketBase.RootCmd
+// は具象構造体のフィールドであり、core.App インタフェースには含まれないため。
+func registerReleaseCmd(app core.App, rootCmd *cobra.Command, registry *ReleaseRegistry) {
+	var force bool
+
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply all pending releases in dependency order",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return registry.Apply(app, force)
+		},
+	}
+	applyCmd.Flags().BoolVar(&force, "force", false, "reapply releases even if their checksum has changed")
+
+	releaseCmd := &cobra.Command{
+		Use:   "release",
+		Short: "Manage versioned releases of non-DB resources (S3, webhooks, OAuth, ...)",
+	}
+	releaseCmd.AddCommand(applyCmd)
+
+	rootCmd.AddCommand(releaseCmd)
+}
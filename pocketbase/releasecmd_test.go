@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func namesOf(releases []*Release) []string {
+	names := make([]string, len(releases))
+	for i, r := range releases {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func TestReleaseRegistrySortedReleasesOrdersByDependency(t *testing.T) {
+	reg := NewReleaseRegistry()
+	reg.Register(&Release{Name: "seed-admin"})
+	reg.Register(&Release{Name: "oauth-config", DependsOn: []string{"seed-admin"}})
+	reg.Register(&Release{Name: "webhooks", DependsOn: []string{"oauth-config", "seed-admin"}})
+
+	ordered, err := reg.sortedReleases()
+	if err != nil {
+		t.Fatalf("sortedReleases() error = %v", err)
+	}
+
+	names := namesOf(ordered)
+	if indexOf(names, "seed-admin") > indexOf(names, "oauth-config") {
+		t.Errorf("expected seed-admin before oauth-config, got order %v", names)
+	}
+	if indexOf(names, "oauth-config") > indexOf(names, "webhooks") {
+		t.Errorf("expected oauth-config before webhooks, got order %v", names)
+	}
+}
+
+func TestReleaseRegistrySortedReleasesDetectsCycle(t *testing.T) {
+	reg := NewReleaseRegistry()
+	reg.Register(&Release{Name: "a", DependsOn: []string{"b"}})
+	reg.Register(&Release{Name: "b", DependsOn: []string{"a"}})
+
+	if _, err := reg.sortedReleases(); err == nil {
+		t.Error("expected sortedReleases() to fail on a circular dependency")
+	}
+}
+
+func TestReleaseRegistrySortedReleasesUnknownDependency(t *testing.T) {
+	reg := NewReleaseRegistry()
+	reg.Register(&Release{Name: "a", DependsOn: []string{"missing"}})
+
+	if _, err := reg.sortedReleases(); err == nil {
+		t.Error("expected sortedReleases() to fail on an unknown dependency")
+	}
+}
+
+func TestReleaseChecksumChangesWithSource(t *testing.T) {
+	a := &Release{Name: "r", Source: "v1"}
+	b := &Release{Name: "r", Source: "v2"}
+
+	if a.checksum() == b.checksum() {
+		t.Error("expected releases with different Source to have different checksums")
+	}
+	if a.checksum() != (&Release{Name: "r", Source: "v1"}).checksum() {
+		t.Error("expected checksum to be deterministic for the same Source")
+	}
+}